@@ -0,0 +1,141 @@
+package hdf5
+
+// Dataset-creation property lists: chunking, compression, and other
+// filters configured before File.CreateDatasetWithProps.
+
+// #include "hdf5.h"
+// #include <stdlib.h>
+import "C"
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"unsafe"
+)
+
+// H5Z_filter_t identifies a registered HDF5 filter (deflate, shuffle,
+// fletcher32, or a third-party filter such as blosc/zstd/lz4).
+type H5Z_filter_t C.H5Z_filter_t
+
+const (
+	Z_FILTER_DEFLATE    H5Z_filter_t = C.H5Z_FILTER_DEFLATE
+	Z_FILTER_SHUFFLE    H5Z_filter_t = C.H5Z_FILTER_SHUFFLE
+	Z_FILTER_FLETCHER32 H5Z_filter_t = C.H5Z_FILTER_FLETCHER32
+)
+
+// DatasetCreateProps is a dataset-creation property list (H5P_DATASET_CREATE).
+type DatasetCreateProps struct {
+	Location
+}
+
+// NewDatasetCreateProps creates an empty dataset-creation property list.
+func NewDatasetCreateProps() (*DatasetCreateProps, error) {
+	hid := C.H5Pcreate(C.H5P_DATASET_CREATE)
+	if hid < 0 {
+		return nil, dynamic_error(7400)
+	}
+	p := &DatasetCreateProps{Location{hid}}
+	runtime.SetFinalizer(p, (*DatasetCreateProps).finalizer)
+	return p, nil
+}
+
+func (p *DatasetCreateProps) finalizer() {
+	err := p.Close()
+	if err != nil {
+		panic(fmt.Sprintf("error closing property list: %s", err))
+	}
+}
+
+// Close releases the property list.
+func (p *DatasetCreateProps) Close() error {
+	if p.id > 0 {
+		err := h5err(C.H5Pclose(p.id))
+		p.id = 0
+		return err
+	}
+	return nil
+}
+
+// SetChunk makes the dataset chunked, with one chunk per dims. dims
+// must have the same rank as the dataset's dataspace.
+func (p *DatasetCreateProps) SetChunk(dims []uint) error {
+	if len(dims) == 0 {
+		return dynamic_error(7401)
+	}
+	c_dims := make([]C.hsize_t, len(dims))
+	for i, d := range dims {
+		c_dims[i] = C.hsize_t(d)
+	}
+	return h5err(C.H5Pset_chunk(p.id, C.int(len(dims)), &c_dims[0]))
+}
+
+// SetDeflate adds gzip/deflate compression at the given level (0-9).
+// The dataset must already be chunked via SetChunk.
+func (p *DatasetCreateProps) SetDeflate(level int) error {
+	return h5err(C.H5Pset_deflate(p.id, C.uint(level)))
+}
+
+// SetShuffle adds the byte-shuffle filter, which typically improves the
+// ratio of a following SetDeflate/SetFilter call.
+func (p *DatasetCreateProps) SetShuffle() error {
+	return h5err(C.H5Pset_shuffle(p.id))
+}
+
+// SetFletcher32 adds the fletcher32 checksum filter.
+func (p *DatasetCreateProps) SetFletcher32() error {
+	return h5err(C.H5Pset_fletcher32(p.id))
+}
+
+// SetFilter adds a third-party filter (e.g. blosc, zstd, lz4) by its
+// registered id, first checking that the filter is available via
+// H5Zfilter_avail.
+func (p *DatasetCreateProps) SetFilter(id H5Z_filter_t, flags uint, cd []uint) error {
+	if C.H5Zfilter_avail(C.H5Z_filter_t(id)) <= 0 {
+		return dynamic_error(7402)
+	}
+	var c_cd *C.uint
+	if len(cd) > 0 {
+		c_values := make([]C.uint, len(cd))
+		for i, v := range cd {
+			c_values[i] = C.uint(v)
+		}
+		c_cd = &c_values[0]
+	}
+	return h5err(C.H5Pset_filter(p.id, C.H5Z_filter_t(id), C.uint(flags), C.size_t(len(cd)), c_cd))
+}
+
+// SetFillValue sets the dataset's fill value to v, a Go scalar matching
+// dt, marshaled with the same reflection-based encoding used by
+// Dataset.Put.
+func (p *DatasetCreateProps) SetFillValue(v interface{}, dt *Datatype) error {
+	if v == nil {
+		return dynamic_error(7405)
+	}
+	dtSize := int(C.H5Tget_size(dt.id))
+	goSize, err := cSizeOf(reflect.TypeOf(v))
+	if err != nil {
+		return err
+	}
+	if goSize != dtSize {
+		return dynamic_error(7404)
+	}
+	buf := make([]byte, dtSize)
+	var allocs []*C.char
+	encodeValue(reflect.ValueOf(v), unsafe.Pointer(&buf[0]), &allocs)
+	defer freeCStrings(allocs)
+	return h5err(C.H5Pset_fill_value(p.id, dt.id, unsafe.Pointer(&buf[0])))
+}
+
+// CreateDatasetWithProps creates a new dataset named name, like
+// CreateDataset, but applies dcpl (e.g. chunking and compression
+// configured via DatasetCreateProps) at creation time.
+func (f *File) CreateDatasetWithProps(name string, dt *Datatype, ds *Dataspace, dcpl *DatasetCreateProps) (*Dataset, error) {
+	c_name := C.CString(name)
+	defer C.free(unsafe.Pointer(c_name))
+	hid := C.H5Dcreate2(f.id, c_name, dt.id, ds.id, C.H5P_DEFAULT, dcpl.id, C.H5P_DEFAULT)
+	if hid < 0 {
+		return nil, dynamic_error(7403)
+	}
+	return &Dataset{Location{hid}}, nil
+}
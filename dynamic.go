@@ -8,6 +8,7 @@ package hdf5
 // #include "hdf5.h"
 // #include "H5PTpublic.h"
 // #include <stdlib.h>
+// #include <string.h>
 // static inline int _go_hdf5_sizeof_uintptr() { return sizeof(void*); }
 import "C"
 
@@ -136,59 +137,256 @@ func (t *Datatype) AsDynamic() *DynDatatype {
 	return NewDynDatatype(hid)
 }
 
+// openTableDatatype returns the compound datatype of t's packets. Most
+// tables expose this directly via H5PTget_type_id; for the (rare)
+// packet table implementation that doesn't, it falls back to the
+// underlying dataset's datatype via H5Dget_type. The caller owns the
+// returned DynDatatype.
+func openTableDatatype(t *Table) (*DynDatatype, error) {
+	hid := C.H5PTget_type_id(t.id)
+	if hid < 0 {
+		hid = C.H5Dget_type(t.id)
+		if hid < 0 {
+			return nil, dynamic_error(7390)
+		}
+	}
+	return NewDynDatatype(hid), nil
+}
+
+// TableReader streams the records of a packet table as raw bytes,
+// batching reads of as many whole records as fit into an internal C
+// buffer. Use Next/Record/Err to iterate:
+//
+//	rdr, err := table.MakeTableReader()
+//	for rdr.Next() {
+//	    process(rdr.Record())
+//	}
+//	if err := rdr.Err(); err != nil { ... }
 type TableReader struct {
-	source	 	*Table
-	bytesPerRecord	int
-	buffer		unsafe.Pointer
-	bufferCap	int
-	remain		int64
+	source         *Table
+	dt             *DynDatatype
+	bytesPerRecord int
+	buffer         unsafe.Pointer
+	bufferCap      int
+	remain         int64
+
+	batchCount int
+	batchIndex int
+	err        error
+
+	hasIndex bool
+
+	// pendingReclaimPtr/Count describe the most recent H5PTget_next (or
+	// ReadInto) buffer whose VLEN/string allocations have not yet been
+	// released via H5Dvlen_reclaim. They're reclaimed on the next refill,
+	// ReadInto, SetBufferSize or Seek call, or in the finalizer,
+	// by which point the caller has already copied any Go-owned data
+	// (e.g. via Unpacker.Unpack) out of it.
+	pendingReclaimPtr   unsafe.Pointer
+	pendingReclaimCount int
+}
+
+func (rdr *TableReader) reclaimPending() error {
+	if rdr.pendingReclaimCount == 0 {
+		return nil
+	}
+	err := reclaimVlen(rdr.dt, int64(rdr.pendingReclaimCount), rdr.pendingReclaimPtr)
+	rdr.pendingReclaimCount = 0
+	return err
 }
 
 func (rdr *TableReader) finalizer() {
+	if err := rdr.reclaimPending(); err != nil {
+		panic(fmt.Sprintf("error reclaiming packet table vlen data: %s", err))
+	}
 	C.free(rdr.buffer)
+	if rdr.hasIndex {
+		if rc := C.H5PTclose_index(rdr.source.id); rc < 0 {
+			panic(fmt.Sprintf("error closing packet table index: %s", h5err(rc)))
+		}
+	}
+	rdr.dt.Close()
 }
 
-type DynPacket struct {
-	Data		[]byte
+// RecordSize returns the size, in bytes, of one record as derived from
+// the packet table's own compound datatype.
+func (rdr *TableReader) RecordSize() int {
+	return rdr.bytesPerRecord
 }
 
-// Returns a slice of DynPacket objects, each of which
-// contains a byte slice.  Reuses the underlying storage
-// associated with the TableReader.  Will return fewer
-// objects if the num of them don't fit in the buffer, or
-// there are fewer left.  Returns nil at EOF (but no
-// error is indicated)
+// SetBufferSize resizes the reader's internal batch buffer, which must
+// be able to hold at least one record. H5PTget_next advances the packet
+// table's own read cursor for the whole batch at refill time, so any
+// records sitting in the buffer but not yet visited via Next are no
+// longer at the table's read cursor; before resizing, SetBufferSize
+// repositions the table (via the same H5PTcreate_index/H5PTset_index
+// mechanism Seek uses) back to the first undelivered record, so none of
+// them are lost.
+func (rdr *TableReader) SetBufferSize(bytes int) error {
+	if bytes < rdr.bytesPerRecord {
+		return dynamic_error(7392)
+	}
+	if err := rdr.reclaimPending(); err != nil {
+		return err
+	}
+
+	c_nrecords := C.hsize_t(0)
+	if rc := C.H5PTget_num_packets(rdr.source.id, &c_nrecords); rc < 0 {
+		return h5err(rc)
+	}
+	total := int64(c_nrecords)
+
+	var undelivered int64
+	if rdr.batchCount > 0 {
+		undelivered = int64(rdr.batchCount - rdr.batchIndex - 1)
+	}
+	nextIndex := (total - rdr.remain) - undelivered
+
+	if !rdr.hasIndex {
+		if rc := C.H5PTcreate_index(rdr.source.id); rc < 0 {
+			return h5err(rc)
+		}
+		rdr.hasIndex = true
+	}
+	if rc := C.H5PTset_index(rdr.source.id, C.hsize_t(nextIndex)); rc < 0 {
+		return h5err(rc)
+	}
+	rdr.remain = total - nextIndex
+
+	newbuf := C.realloc(rdr.buffer, C.size_t(bytes))
+	if newbuf == nil {
+		return dynamic_error(7393)
+	}
+	rdr.buffer = newbuf
+	rdr.bufferCap = bytes
+	rdr.batchCount = 0
+	rdr.batchIndex = 0
+	return nil
+}
+
+// Seek repositions the reader to recordIndex, discarding any buffered
+// but unread records.
+func (rdr *TableReader) Seek(recordIndex int64) error {
+	if err := rdr.reclaimPending(); err != nil {
+		return err
+	}
+	if !rdr.hasIndex {
+		if rc := C.H5PTcreate_index(rdr.source.id); rc < 0 {
+			return h5err(rc)
+		}
+		rdr.hasIndex = true
+	}
+	if rc := C.H5PTset_index(rdr.source.id, C.hsize_t(recordIndex)); rc < 0 {
+		return h5err(rc)
+	}
+	c_nrecords := C.hsize_t(0)
+	if rc := C.H5PTget_num_packets(rdr.source.id, &c_nrecords); rc < 0 {
+		return h5err(rc)
+	}
+	total := int64(c_nrecords)
+	if recordIndex > total {
+		recordIndex = total
+	}
+	rdr.remain = total - recordIndex
+	rdr.batchCount = 0
+	rdr.batchIndex = 0
+	rdr.err = nil
+	return nil
+}
 
-func (rdr *TableReader) Read(num int) ([]DynPacket, error) {
-	if num * rdr.bytesPerRecord > rdr.bufferCap {
-		num = rdr.bufferCap / rdr.bytesPerRecord
+// refill reclaims the previous batch's VLEN allocations and reads the
+// next batch of records into rdr.buffer.
+func (rdr *TableReader) refill() bool {
+	if err := rdr.reclaimPending(); err != nil {
+		rdr.err = err
+		return false
+	}
+	rdr.batchCount = 0
+	rdr.batchIndex = 0
+	if rdr.remain == 0 {
+		return false
 	}
+	num := rdr.bufferCap / rdr.bytesPerRecord
 	if int64(num) > rdr.remain {
 		num = int(rdr.remain)
 	}
 	if num == 0 {
-		// EOF
-		return nil, nil
+		return false
 	}
 	rc := C.H5PTget_next(rdr.source.id, C.size_t(num), rdr.buffer)
 	if rc < 0 {
-		return nil, h5err(rc)
+		rdr.err = h5err(rc)
+		return false
 	}
 	rdr.remain -= int64(num)
-	vec := make([]DynPacket, num)
-	var p uintptr = uintptr(rdr.buffer)
-	for i := 0; i < num; i++ {
-		hdr := reflect.SliceHeader{
-			Data: p,
-			Len: rdr.bytesPerRecord,
-			Cap: rdr.bytesPerRecord,
-		}
-		vec[i] = DynPacket{
-			Data: *(*[]byte)(unsafe.Pointer(&hdr)),
-			}
-		p += uintptr(rdr.bytesPerRecord)
+	rdr.batchCount = num
+	rdr.batchIndex = 0
+	rdr.pendingReclaimPtr = rdr.buffer
+	rdr.pendingReclaimCount = num
+	return true
+}
+
+// Next advances to the next record, refilling the internal buffer from
+// the packet table as needed. It returns false at EOF or on error; call
+// Err to distinguish the two.
+func (rdr *TableReader) Next() bool {
+	if rdr.err != nil {
+		return false
 	}
-	return vec, nil
+	rdr.batchIndex++
+	if rdr.batchIndex < rdr.batchCount {
+		return true
+	}
+	return rdr.refill()
+}
+
+// Record returns the current record as a slice aliasing the reader's
+// internal buffer. The slice is only valid until the next call to Next,
+// SetBufferSize, or Seek, or until the reader is garbage collected.
+func (rdr *TableReader) Record() []byte {
+	if rdr.batchIndex < 0 || rdr.batchIndex >= rdr.batchCount {
+		return nil
+	}
+	p := uintptr(rdr.buffer) + uintptr(rdr.batchIndex*rdr.bytesPerRecord)
+	hdr := reflect.SliceHeader{Data: p, Len: rdr.bytesPerRecord, Cap: rdr.bytesPerRecord}
+	return *(*[]byte)(unsafe.Pointer(&hdr))
+}
+
+// Err returns the first error encountered by Next, or nil if iteration
+// ended because there were no more records.
+func (rdr *TableReader) Err() error {
+	return rdr.err
+}
+
+// ReadInto reads as many whole records as fit in dst directly from the
+// packet table, bypassing the reader's own buffer, for callers that
+// want to manage their own memory. It returns the number of records
+// read. Any VLEN/string allocations in dst are reclaimed at the start
+// of the next ReadInto call (or when rdr is garbage collected), so
+// callers must finish extracting Go-owned data from dst before calling
+// ReadInto again.
+func (rdr *TableReader) ReadInto(dst []byte) (int, error) {
+	if err := rdr.reclaimPending(); err != nil {
+		return 0, err
+	}
+	if len(dst) < rdr.bytesPerRecord {
+		return 0, dynamic_error(7394)
+	}
+	num := len(dst) / rdr.bytesPerRecord
+	if int64(num) > rdr.remain {
+		num = int(rdr.remain)
+	}
+	if num == 0 {
+		return 0, nil
+	}
+	rc := C.H5PTget_next(rdr.source.id, C.size_t(num), unsafe.Pointer(&dst[0]))
+	if rc < 0 {
+		return 0, h5err(rc)
+	}
+	rdr.remain -= int64(num)
+	rdr.pendingReclaimPtr = unsafe.Pointer(&dst[0])
+	rdr.pendingReclaimCount = num
+	return num, nil
 }
 
 func (t *Table) MakeTableReader() (*TableReader, error) {
@@ -202,24 +400,45 @@ func (t *Table) MakeTableReader() (*TableReader, error) {
 		return nil, h5err(rc)
 	}
 
+	dt, err := openTableDatatype(t)
+	if err != nil {
+		return nil, err
+	}
+	per := int(dt.Size())
+	if per == 0 {
+		dt.Close()
+		return nil, dynamic_error(7391)
+	}
+
 	bcap := 1000000
+	if bcap < per {
+		bcap = per
+	}
 	var buf unsafe.Pointer = C.malloc(C.size_t(bcap))
 
-	per := 32
 	rdr := &TableReader{
-		source: t, 
-		bytesPerRecord: per, 
-		buffer: buf, 
-		bufferCap: bcap,
-		remain: int64(c_nrecords)}
+		source:         t,
+		dt:             dt,
+		bytesPerRecord: per,
+		buffer:         buf,
+		bufferCap:      bcap,
+		remain:         int64(c_nrecords),
+		hasIndex:       true,
+	}
 	runtime.SetFinalizer(rdr, (*TableReader).finalizer)
 	return rdr, nil
 }
 
 func (t *Table) ReadPacketBytes(start int, count int) []byte {
-	per := 24
-	var buf unsafe.Pointer = C.malloc(1000000)
+	dt, err := openTableDatatype(t)
+	if err != nil {
+		return nil
+	}
+	defer dt.Close()
+	per := int(dt.Size())
+
 	nbytes := count * per
+	var buf unsafe.Pointer = C.malloc(C.size_t(nbytes))
 
 	rc := C.H5PTread_packets(t.id, C.hsize_t(start), C.size_t(count), buf)
 	if rc < 0 {
@@ -232,9 +451,29 @@ func (t *Table) ReadPacketBytes(start int, count int) []byte {
 
 type primitiveUnpack func (p uintptr) (interface{}, uintptr)
 
+func charUnpacker(p uintptr) (interface{}, uintptr) {
+	return *(*int8)(unsafe.Pointer(p)), p + 1
+}
+
+func ucharUnpacker(p uintptr) (interface{}, uintptr) {
+	return *(*uint8)(unsafe.Pointer(p)), p + 1
+}
+
+func shortUnpacker(p uintptr) (interface{}, uintptr) {
+	return *(*int16)(unsafe.Pointer(p)), p + 2
+}
+
+func ushortUnpacker(p uintptr) (interface{}, uintptr) {
+	return *(*uint16)(unsafe.Pointer(p)), p + 2
+}
+
 func integerUnpacker(p uintptr) (interface{}, uintptr) {
 	var tmp int32 = *(*int32)(unsafe.Pointer(p))
-	return tmp, p + 8
+	return tmp, p + 4
+}
+
+func uintUnpacker(p uintptr) (interface{}, uintptr) {
+	return *(*uint32)(unsafe.Pointer(p)), p + 4
 }
 
 func longUnpacker(p uintptr) (interface{}, uintptr) {
@@ -242,13 +481,24 @@ func longUnpacker(p uintptr) (interface{}, uintptr) {
 	return tmp, p + 8
 }
 
+func ullongUnpacker(p uintptr) (interface{}, uintptr) {
+	return *(*uint64)(unsafe.Pointer(p)), p + 8
+}
+
 func floatUnpacker(p uintptr) (interface{}, uintptr) {
 	var tmp float32 = *(*float32)(unsafe.Pointer(p))
 	return tmp, p + 4
 }
 
+func doubleUnpacker(p uintptr) (interface{}, uintptr) {
+	return *(*float64)(unsafe.Pointer(p)), p + 8
+}
+
+func hboolUnpacker(p uintptr) (interface{}, uintptr) {
+	return *(*byte)(unsafe.Pointer(p)) != 0, p + 1
+}
+
 func stringUnpacker(p uintptr) (interface{}, uintptr) {
-	// god knows what we're leaking...
 	var c_str uintptr = *(*uintptr)(unsafe.Pointer(p))
 	nextp := p + uintptr(C._go_hdf5_sizeof_uintptr())
 	if c_str == 0 {
@@ -258,6 +508,89 @@ func stringUnpacker(p uintptr) (interface{}, uintptr) {
 	}
 }
 
+// fixedStringUnpacker returns an unpacker for a fixed-length (non-VLEN)
+// string member: size bytes are copied out and trailing NULs trimmed.
+func fixedStringUnpacker(size int) primitiveUnpack {
+	return func(p uintptr) (interface{}, uintptr) {
+		buf := C.GoBytes(unsafe.Pointer(p), C.int(size))
+		n := len(buf)
+		for n > 0 && buf[n-1] == 0 {
+			n--
+		}
+		return string(buf[:n]), p + uintptr(size)
+	}
+}
+
+// arrayUnpacker returns an unpacker for a fixed-size array member: count
+// elements, each decoded by elem and elemSize bytes wide.
+func arrayUnpacker(elem primitiveUnpack, count int, elemSize int) primitiveUnpack {
+	return func(p uintptr) (interface{}, uintptr) {
+		out := make([]interface{}, count)
+		q := p
+		for i := 0; i < count; i++ {
+			out[i], q = elem(q)
+		}
+		return out, p + uintptr(count*elemSize)
+	}
+}
+
+// EnumValue is what Unpack returns for an enum-typed compound member:
+// the raw underlying integer value plus its resolved symbolic name
+// (empty if the value doesn't match any enum member).
+type EnumValue struct {
+	Value int64
+	Name  string
+}
+
+func toInt64(v interface{}) int64 {
+	switch x := v.(type) {
+	case int8:
+		return int64(x)
+	case uint8:
+		return int64(x)
+	case int16:
+		return int64(x)
+	case uint16:
+		return int64(x)
+	case int32:
+		return int64(x)
+	case uint32:
+		return int64(x)
+	case int64:
+		return x
+	case uint64:
+		return int64(x)
+	default:
+		return 0
+	}
+}
+
+// enumUnpacker returns an unpacker for an enum member: under decodes
+// the underlying integer representation, which is then resolved
+// against names.
+func enumUnpacker(under primitiveUnpack, names map[int64]string) primitiveUnpack {
+	return func(p uintptr) (interface{}, uintptr) {
+		v, next := under(p)
+		return EnumValue{Value: toInt64(v), Name: names[toInt64(v)]}, next
+	}
+}
+
+// compoundUnpacker returns an unpacker for a nested compound member: sub
+// decodes it into a []interface{} which is then reassembled into a
+// map[string]interface{} keyed by member name.
+func compoundUnpacker(sub *Unpacker, names []string, size int) primitiveUnpack {
+	return func(p uintptr) (interface{}, uintptr) {
+		data := C.GoBytes(unsafe.Pointer(p), C.int(size))
+		vals := make([]interface{}, len(names))
+		sub.unpackInto(data, vals)
+		m := make(map[string]interface{}, len(vals))
+		for i, name := range names {
+			m[name] = vals[i]
+		}
+		return m, p + uintptr(size)
+	}
+}
+
 type Unpacker struct {
 	unpackers	[]primitiveUnpack
 }
@@ -271,20 +604,126 @@ func (t *DynDatatype) equal(b *Datatype) bool {
 }
 	
 
+// primitiveUnpacker resolves a member's HDF5 datatype to the unpacker
+// function that can decode it. Every native numeric type, fixed and
+// variable-length strings, arrays, enums, and nested compounds are
+// covered; anything else is a real error rather than a silently-skipped
+// member.
 func (t *DynDatatype) primitiveUnpacker() (primitiveUnpack, error) {
 	switch {
 	case t.equal(T_NATIVE_LLONG):
 		return longUnpacker, nil
 	case t.equal(T_NATIVE_INT):
 		return integerUnpacker, nil
+	case t.equal(T_NATIVE_UINT):
+		return uintUnpacker, nil
+	case t.equal(T_NATIVE_ULLONG):
+		return ullongUnpacker, nil
+	case t.equal(T_NATIVE_SHORT):
+		return shortUnpacker, nil
+	case t.equal(T_NATIVE_USHORT):
+		return ushortUnpacker, nil
+	case t.equal(T_NATIVE_CHAR), t.equal(T_NATIVE_SCHAR):
+		return charUnpacker, nil
+	case t.equal(T_NATIVE_UCHAR):
+		return ucharUnpacker, nil
 	case t.equal(T_NATIVE_FLOAT):
 		return floatUnpacker, nil
+	case t.equal(T_NATIVE_DOUBLE):
+		return doubleUnpacker, nil
+	case t.equal(T_NATIVE_HBOOL):
+		return hboolUnpacker, nil
 	case t.equal(T_GO_STRING):
 		return stringUnpacker, nil
-	default:
-		// unsupported type for unpacking
-		return nil, dynamic_error(7307)
 	}
+
+	switch t.class {
+	case T_STRING:
+		if C.H5Tis_variable_str(t.id) > 0 {
+			return stringUnpacker, nil
+		}
+		return fixedStringUnpacker(int(t.Size())), nil
+	case T_ENUM:
+		return t.enumUnpacker()
+	case T_ARRAY:
+		return t.arrayUnpacker()
+	case T_COMPOUND:
+		return t.compoundUnpacker()
+	}
+	// unsupported type for unpacking
+	return nil, dynamic_error(7307)
+}
+
+// enumUnpacker builds an unpacker for an enum datatype: it decodes the
+// underlying integer type, then resolves the value against a name map
+// built from H5Tget_member_name/H5Tget_member_value.
+func (t *DynDatatype) enumUnpacker() (primitiveUnpack, error) {
+	super, err := t.Super()
+	if err != nil {
+		return nil, err
+	}
+	defer super.Close()
+	under, err := super.primitiveUnpacker()
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := t.EnumMembers()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int64]string, len(members))
+	for name, v := range members {
+		names[v] = name
+	}
+	return enumUnpacker(under, names), nil
+}
+
+// arrayUnpacker builds an unpacker for a fixed-size array datatype.
+func (t *DynDatatype) arrayUnpacker() (primitiveUnpack, error) {
+	super, err := t.Super()
+	if err != nil {
+		return nil, err
+	}
+	defer super.Close()
+	elem, err := super.primitiveUnpacker()
+	if err != nil {
+		return nil, err
+	}
+	count, err := t.arrayElementCount()
+	if err != nil {
+		return nil, err
+	}
+	return arrayUnpacker(elem, count, int(super.Size())), nil
+}
+
+// arrayElementCount returns the total number of elements described by
+// an H5T_ARRAY datatype (the product of its dimensions).
+func (t *DynDatatype) arrayElementCount() (int, error) {
+	dims, err := t.ArrayDims()
+	if err != nil {
+		return 0, err
+	}
+	count := 1
+	for _, d := range dims {
+		count *= int(d)
+	}
+	return count, nil
+}
+
+// compoundUnpacker builds an unpacker for a nested compound member,
+// decoding it into a map[string]interface{} keyed by member name.
+func (t *DynDatatype) compoundUnpacker() (primitiveUnpack, error) {
+	sub, err := t.MakeUnpacker()
+	if err != nil {
+		return nil, err
+	}
+	n, _ := t.NMembers()
+	names := make([]string, n)
+	for i := 0; i < n; i++ {
+		names[i] = t.MemberName(i)
+	}
+	return compoundUnpacker(sub, names, int(t.Size())), nil
 }
 
 func (t *DynDatatype) MakeUnpacker() (*Unpacker, error) {
@@ -298,25 +737,379 @@ func (t *DynDatatype) MakeUnpacker() (*Unpacker, error) {
 		if err != nil {
 			return nil, err
 		}
-		
-		ufunc, _ := mt.primitiveUnpacker()
-		/*if err != nil {
+		ufunc, err := mt.primitiveUnpacker()
+		if err != nil {
 			return nil, err
-		}*/
+		}
 		u[i] = ufunc
 	}
 	return &Unpacker{u}, nil
 }
 
-func (u *Unpacker) Unpack(data []byte) []interface{} {
+// unpackInto is the shared implementation behind Unpack, used both by
+// the public entry point and by compoundUnpacker decoding a nested
+// record into a scratch slice.
+func (u *Unpacker) unpackInto(data []byte, dst []interface{}) {
 	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&data))
 	var p uintptr = hdr.Data
-	n := len(u.unpackers)
-	result := make([]interface{}, n)
+	for i, unpack := range u.unpackers {
+		dst[i], p = unpack(p)
+	}
+}
+
+// Unpack decodes one record of data into dst, which must have at least
+// len(u.unpackers) elements. Unlike the old by-value Unpack, this fills
+// a caller-owned slice so a consumer reading many records at
+// packet-table throughput can reuse one []interface{} instead of
+// allocating a fresh one per row.
+func (u *Unpacker) Unpack(data []byte, dst []interface{}) error {
+	if len(dst) < len(u.unpackers) {
+		return dynamic_error(7352)
+	}
+	u.unpackInto(data, dst)
+	return nil
+}
+
+// NumFields returns the number of top-level members this Unpacker
+// decodes, i.e. the minimum length required of a dst slice passed to
+// Unpack.
+func (u *Unpacker) NumFields() int {
+	return len(u.unpackers)
+}
+
+// primitivePack is the write-side counterpart to primitiveUnpack: it
+// encodes v at p, appending any C allocation it made (e.g. for a
+// variable-length string) to allocs so the caller can free it once the
+// write that consumes the buffer has returned.
+type primitivePack func(v interface{}, p uintptr, allocs *[]*C.char) (uintptr, error)
+
+func charPacker(v interface{}, p uintptr, allocs *[]*C.char) (uintptr, error) {
+	iv, ok := v.(int8)
+	if !ok {
+		return p, dynamic_error(7357)
+	}
+	*(*int8)(unsafe.Pointer(p)) = iv
+	return p + 1, nil
+}
+
+func ucharPacker(v interface{}, p uintptr, allocs *[]*C.char) (uintptr, error) {
+	iv, ok := v.(uint8)
+	if !ok {
+		return p, dynamic_error(7358)
+	}
+	*(*uint8)(unsafe.Pointer(p)) = iv
+	return p + 1, nil
+}
+
+func shortPacker(v interface{}, p uintptr, allocs *[]*C.char) (uintptr, error) {
+	iv, ok := v.(int16)
+	if !ok {
+		return p, dynamic_error(7359)
+	}
+	*(*int16)(unsafe.Pointer(p)) = iv
+	return p + 2, nil
+}
+
+func ushortPacker(v interface{}, p uintptr, allocs *[]*C.char) (uintptr, error) {
+	iv, ok := v.(uint16)
+	if !ok {
+		return p, dynamic_error(7360)
+	}
+	*(*uint16)(unsafe.Pointer(p)) = iv
+	return p + 2, nil
+}
+
+func integerPacker(v interface{}, p uintptr, allocs *[]*C.char) (uintptr, error) {
+	iv, ok := v.(int32)
+	if !ok {
+		return p, dynamic_error(7361)
+	}
+	*(*int32)(unsafe.Pointer(p)) = iv
+	return p + 4, nil
+}
+
+func uintPacker(v interface{}, p uintptr, allocs *[]*C.char) (uintptr, error) {
+	iv, ok := v.(uint32)
+	if !ok {
+		return p, dynamic_error(7362)
+	}
+	*(*uint32)(unsafe.Pointer(p)) = iv
+	return p + 4, nil
+}
+
+func longPacker(v interface{}, p uintptr, allocs *[]*C.char) (uintptr, error) {
+	iv, ok := v.(int64)
+	if !ok {
+		return p, dynamic_error(7363)
+	}
+	*(*int64)(unsafe.Pointer(p)) = iv
+	return p + 8, nil
+}
+
+func ullongPacker(v interface{}, p uintptr, allocs *[]*C.char) (uintptr, error) {
+	iv, ok := v.(uint64)
+	if !ok {
+		return p, dynamic_error(7364)
+	}
+	*(*uint64)(unsafe.Pointer(p)) = iv
+	return p + 8, nil
+}
+
+func floatPacker(v interface{}, p uintptr, allocs *[]*C.char) (uintptr, error) {
+	fv, ok := v.(float32)
+	if !ok {
+		return p, dynamic_error(7365)
+	}
+	*(*float32)(unsafe.Pointer(p)) = fv
+	return p + 4, nil
+}
+
+func doublePacker(v interface{}, p uintptr, allocs *[]*C.char) (uintptr, error) {
+	fv, ok := v.(float64)
+	if !ok {
+		return p, dynamic_error(7366)
+	}
+	*(*float64)(unsafe.Pointer(p)) = fv
+	return p + 8, nil
+}
+
+func hboolPacker(v interface{}, p uintptr, allocs *[]*C.char) (uintptr, error) {
+	bv, ok := v.(bool)
+	if !ok {
+		return p, dynamic_error(7367)
+	}
+	if bv {
+		*(*byte)(unsafe.Pointer(p)) = 1
+	} else {
+		*(*byte)(unsafe.Pointer(p)) = 0
+	}
+	return p + 1, nil
+}
+
+func stringPacker(v interface{}, p uintptr, allocs *[]*C.char) (uintptr, error) {
+	s, ok := v.(string)
+	if !ok {
+		return p, dynamic_error(7368)
+	}
+	c_str := C.CString(s)
+	*allocs = append(*allocs, c_str)
+	*(*uintptr)(unsafe.Pointer(p)) = uintptr(unsafe.Pointer(c_str))
+	return p + uintptr(C._go_hdf5_sizeof_uintptr()), nil
+}
+
+// fixedStringPacker returns a packer for a fixed-length (non-VLEN)
+// string member: the Go string is copied in, NUL-padded or truncated to
+// size bytes.
+func fixedStringPacker(size int) primitivePack {
+	return func(v interface{}, p uintptr, allocs *[]*C.char) (uintptr, error) {
+		s, ok := v.(string)
+		if !ok {
+			return p, dynamic_error(7369)
+		}
+		buf := make([]byte, size)
+		copy(buf, s)
+		if size > 0 {
+			C.memcpy(unsafe.Pointer(p), unsafe.Pointer(&buf[0]), C.size_t(size))
+		}
+		return p + uintptr(size), nil
+	}
+}
+
+// primitivePacker resolves a member's HDF5 datatype to the packer
+// function that can encode it, mirroring primitiveUnpacker. Enum
+// members are not yet supported for writing and return a real error
+// rather than being silently skipped.
+func (t *DynDatatype) primitivePacker() (primitivePack, error) {
+	switch {
+	case t.equal(T_NATIVE_LLONG):
+		return longPacker, nil
+	case t.equal(T_NATIVE_INT):
+		return integerPacker, nil
+	case t.equal(T_NATIVE_UINT):
+		return uintPacker, nil
+	case t.equal(T_NATIVE_ULLONG):
+		return ullongPacker, nil
+	case t.equal(T_NATIVE_SHORT):
+		return shortPacker, nil
+	case t.equal(T_NATIVE_USHORT):
+		return ushortPacker, nil
+	case t.equal(T_NATIVE_CHAR), t.equal(T_NATIVE_SCHAR):
+		return charPacker, nil
+	case t.equal(T_NATIVE_UCHAR):
+		return ucharPacker, nil
+	case t.equal(T_NATIVE_FLOAT):
+		return floatPacker, nil
+	case t.equal(T_NATIVE_DOUBLE):
+		return doublePacker, nil
+	case t.equal(T_NATIVE_HBOOL):
+		return hboolPacker, nil
+	case t.equal(T_GO_STRING):
+		return stringPacker, nil
+	}
+
+	switch t.class {
+	case T_STRING:
+		if C.H5Tis_variable_str(t.id) > 0 {
+			return stringPacker, nil
+		}
+		return fixedStringPacker(int(t.Size())), nil
+	case T_ARRAY:
+		return t.arrayPacker()
+	case T_COMPOUND:
+		return t.compoundPacker()
+	}
+	// unsupported type for packing (e.g. enum)
+	return nil, dynamic_error(7370)
+}
+
+// arrayPacker builds a packer for a fixed-size array datatype; v must
+// be a []interface{} of the array's element count.
+func (t *DynDatatype) arrayPacker() (primitivePack, error) {
+	super, err := t.Super()
+	if err != nil {
+		return nil, err
+	}
+	defer super.Close()
+	elem, err := super.primitivePacker()
+	if err != nil {
+		return nil, err
+	}
+	count, err := t.arrayElementCount()
+	if err != nil {
+		return nil, err
+	}
+	elemSize := int(super.Size())
+	return func(v interface{}, p uintptr, allocs *[]*C.char) (uintptr, error) {
+		vals, ok := v.([]interface{})
+		if !ok || len(vals) != count {
+			return p, dynamic_error(7372)
+		}
+		q := p
+		for i := 0; i < count; i++ {
+			var err error
+			q, err = elem(vals[i], q, allocs)
+			if err != nil {
+				return p, err
+			}
+		}
+		return p + uintptr(count*elemSize), nil
+	}, nil
+}
+
+// compoundPacker builds a packer for a nested compound member; v must
+// be a map[string]interface{} keyed by member name.
+func (t *DynDatatype) compoundPacker() (primitivePack, error) {
+	sub, err := t.MakePacker()
+	if err != nil {
+		return nil, err
+	}
+	n, _ := t.NMembers()
+	names := make([]string, n)
 	for i := 0; i < n; i++ {
-		if u.unpackers[i] != nil {
-			result[i], p = u.unpackers[i](p)
+		names[i] = t.MemberName(i)
+	}
+	size := int(t.Size())
+	return func(v interface{}, p uintptr, allocs *[]*C.char) (uintptr, error) {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return p, dynamic_error(7373)
 		}
+		values := make([]interface{}, len(names))
+		for i, name := range names {
+			values[i] = m[name]
+		}
+		buf := make([]byte, size)
+		if err := sub.Pack(values, buf); err != nil {
+			return p, err
+		}
+		if size > 0 {
+			C.memcpy(unsafe.Pointer(p), unsafe.Pointer(&buf[0]), C.size_t(size))
+		}
+		*allocs = append(*allocs, sub.allocs...)
+		sub.allocs = nil
+		return p + uintptr(size), nil
+	}, nil
+}
+
+// Packer encodes records of a known compound datatype into a flat byte
+// buffer suitable for H5PTappend, the symmetric counterpart to Unpacker.
+type Packer struct {
+	packers []primitivePack
+	size    int
+	allocs  []*C.char
+}
+
+// MakePacker builds a Packer for t, which must be a compound datatype.
+func (t *DynDatatype) MakePacker() (*Packer, error) {
+	if t.class != T_COMPOUND {
+		return nil, dynamic_error(7353)
+	}
+	n, _ := t.NMembers()
+	p := make([]primitivePack, n)
+	for i := 0; i < n; i++ {
+		mt, err := t.MemberType(i)
+		if err != nil {
+			return nil, err
+		}
+		pfunc, err := mt.primitivePacker()
+		if err != nil {
+			return nil, err
+		}
+		p[i] = pfunc
+	}
+	pk := &Packer{packers: p, size: int(t.Size())}
+	runtime.SetFinalizer(pk, (*Packer).finalizer)
+	return pk, nil
+}
+
+func (p *Packer) finalizer() {
+	p.FreeStrings()
+}
+
+// Close releases any C strings allocated by the most recent Pack call.
+// Call it once the Packer is no longer needed: the finalizer is only a
+// backstop against a forgotten Close, and relying on it for the final
+// Pack call is unsafe if the caller drops its last reference to the
+// Packer before finishing whatever it's doing with dst (e.g. an
+// H5PTappend still in flight) -- the GC could free those strings out
+// from under it. Call runtime.KeepAlive on the Packer around that use
+// if Close can't be called first.
+func (p *Packer) Close() error {
+	p.FreeStrings()
+	return nil
+}
+
+// Pack encodes one record from values into dst, which must be at least
+// p.size bytes. Any string value allocates a C string that Pack frees
+// at the start of the next call (or via Close/FreeStrings); callers
+// must finish using dst (e.g. via H5PTappend) before calling Pack
+// again, and should call Close once done with the Packer rather than
+// relying on its finalizer (see Close).
+func (p *Packer) Pack(values []interface{}, dst []byte) error {
+	if len(values) != len(p.packers) {
+		return dynamic_error(7374)
+	}
+	if len(dst) < p.size {
+		return dynamic_error(7375)
+	}
+	p.FreeStrings()
+	q := uintptr(unsafe.Pointer(&dst[0]))
+	for i, pack := range p.packers {
+		var err error
+		q, err = pack(values[i], q, &p.allocs)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FreeStrings releases any C strings allocated by the most recent Pack
+// call. Callers that write each buffer to HDF5 before calling Pack
+// again don't need to call this directly.
+func (p *Packer) FreeStrings() {
+	for _, s := range p.allocs {
+		C.free(unsafe.Pointer(s))
 	}
-	return result
+	p.allocs = p.allocs[:0]
 }
@@ -1,10 +1,51 @@
 package hdf5
 
+// #include "H5PTpublic.h"
+// #include <stdlib.h>
+import "C"
+
 import (
+	"encoding/json"
 	"os"
+	"reflect"
 	"testing"
+	"unsafe"
 )
 
+// createTestTable creates a packet table of records in f directly via
+// H5PTcreate, sidestepping any higher-level table-creation helper, so
+// these tests depend on the H5PT* C API rather than on a Go
+// table-creation wrapper.
+func createTestTable(t *testing.T, f *File, rt reflect.Type) *Table {
+	structDT, _, _, err := structDatatype(rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer structDT.Close()
+
+	c_name := C.CString("records")
+	defer C.free(unsafe.Pointer(c_name))
+	hid := C.H5PTcreate(f.id, c_name, structDT.id, C.hsize_t(10), C.H5P_DEFAULT)
+	if hid < 0 {
+		t.Fatal("H5PTcreate failed")
+	}
+	return &Table{Location{hid}}
+}
+
+// createTestDataset creates a dataset of dt in f directly via
+// H5Dcreate2, for tests that need a dataset built from a reflection-
+// derived compound type (CreateDataset only accepts the package's
+// predefined *Datatype constants).
+func createTestDataset(t *testing.T, f *File, dt *DynDatatype, ds *Dataspace) *Dataset {
+	c_name := C.CString("rec")
+	defer C.free(unsafe.Pointer(c_name))
+	hid := C.H5Dcreate2(f.id, c_name, dt.id, ds.id, C.H5P_DEFAULT, C.H5P_DEFAULT, C.H5P_DEFAULT)
+	if hid < 0 {
+		t.Fatal("H5Dcreate2 failed")
+	}
+	return &Dataset{Location{hid}}
+}
+
 func createDataset1(t *testing.T) error {
     // create a file with a single 5x20 dataset
     f, err := CreateFile(FNAME, F_ACC_TRUNC)
@@ -103,3 +144,384 @@ func TestSubset(t *testing.T) {
         t.Fatal("Loaded data does not match expected.",data,expected);
     }
 }
+
+// TestTableGetAllAppendAll round-trips records through the chunk0-1
+// reflection-based AppendAll/GetAll and checks them against the table's
+// actual on-disk compound type via verifyTableLayout.
+func TestTableGetAllAppendAll(t *testing.T) {
+    DisplayErrors(true)
+    defer DisplayErrors(false)
+    defer os.Remove(FNAME)
+
+    f, err := CreateFile(FNAME, F_ACC_TRUNC)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+
+    type ptRecord struct {
+        ID    int32
+        Value float64
+    }
+
+    table := createTestTable(t, f, reflect.TypeOf(ptRecord{}))
+    defer table.Close()
+
+    want := []ptRecord{{1, 1.5}, {2, 2.5}, {3, 3.5}}
+    if err := table.AppendAll(want); err != nil {
+        t.Fatal(err)
+    }
+
+    var got []ptRecord
+    if err := table.GetAll(&got); err != nil {
+        t.Fatal(err)
+    }
+    if len(got) != len(want) {
+        t.Fatalf("got %d records, want %d", len(got), len(want))
+    }
+    for i := range want {
+        if got[i] != want[i] {
+            t.Fatalf("record %d: got %+v, want %+v", i, got[i], want[i])
+        }
+    }
+}
+
+// TestTableReaderSetBufferSizeMidBatch exercises the chunk0-3
+// SetBufferSize fix: resizing the reader's buffer mid-batch must
+// reposition the table's read cursor rather than dropping the
+// records still sitting unread in the old buffer.
+func TestTableReaderSetBufferSizeMidBatch(t *testing.T) {
+    DisplayErrors(true)
+    defer DisplayErrors(false)
+    defer os.Remove(FNAME)
+
+    f, err := CreateFile(FNAME, F_ACC_TRUNC)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+
+    type ptRecord struct {
+        ID int32
+    }
+
+    table := createTestTable(t, f, reflect.TypeOf(ptRecord{}))
+    defer table.Close()
+
+    const n = 20
+    want := make([]ptRecord, n)
+    for i := range want {
+        want[i] = ptRecord{ID: int32(i)}
+    }
+    if err := table.AppendAll(want); err != nil {
+        t.Fatal(err)
+    }
+
+    rdr, err := table.MakeTableReader()
+    if err != nil {
+        t.Fatal(err)
+    }
+    size := rdr.RecordSize()
+    if err := rdr.SetBufferSize(size * 3); err != nil {
+        t.Fatal(err)
+    }
+
+    var got []int32
+    for rdr.Next() {
+        rec := rdr.Record()
+        got = append(got, *(*int32)(unsafe.Pointer(&rec[0])))
+        switch len(got) {
+        case 5:
+            if err := rdr.SetBufferSize(size * 7); err != nil {
+                t.Fatal(err)
+            }
+        case 12:
+            if err := rdr.SetBufferSize(size); err != nil {
+                t.Fatal(err)
+            }
+        }
+    }
+    if err := rdr.Err(); err != nil {
+        t.Fatal(err)
+    }
+
+    if len(got) != n {
+        t.Fatalf("got %d records, want %d", len(got), n)
+    }
+    for i, v := range got {
+        if v != int32(i) {
+            t.Fatalf("record %d: got %d, want %d", i, v, i)
+        }
+    }
+}
+
+// TestDatasetGetPutString round-trips a struct with a string field
+// through Dataset.Get/Put, exercising the VLEN/string path
+// (encodeValue/decodeValue and reclaimVlen) that chunk0-1 exists to
+// fix.
+func TestDatasetGetPutString(t *testing.T) {
+    DisplayErrors(true)
+    defer DisplayErrors(false)
+    defer os.Remove(FNAME)
+
+    f, err := CreateFile(FNAME, F_ACC_TRUNC)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+
+    type strRecord struct {
+        ID   int32
+        Name string
+    }
+
+    structDT, _, _, err := structDatatype(reflect.TypeOf(strRecord{}))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer structDT.Close()
+
+    dims := []uint{1}
+    dspace, err := CreateSimpleDataspace(dims, dims)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    dset := createTestDataset(t, f, structDT, dspace)
+    defer dset.Close()
+
+    want := strRecord{ID: 7, Name: "hello world"}
+    if err := dset.Put(&want); err != nil {
+        t.Fatal(err)
+    }
+
+    var got strRecord
+    if err := dset.Get(&got); err != nil {
+        t.Fatal(err)
+    }
+    if got != want {
+        t.Fatalf("got %+v, want %+v", got, want)
+    }
+}
+
+// TestTableGetAllAppendAllString is the Table counterpart of
+// TestDatasetGetPutString: it round-trips string-bearing records
+// through AppendAll/GetAll, which pulls records through TableReader's
+// batch-reuse/pending-reclaim bookkeeping rather than a single
+// H5Dread/H5Dwrite call.
+func TestTableGetAllAppendAllString(t *testing.T) {
+    DisplayErrors(true)
+    defer DisplayErrors(false)
+    defer os.Remove(FNAME)
+
+    f, err := CreateFile(FNAME, F_ACC_TRUNC)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+
+    type strRecord struct {
+        ID   int32
+        Name string
+    }
+
+    table := createTestTable(t, f, reflect.TypeOf(strRecord{}))
+    defer table.Close()
+
+    want := []strRecord{{1, "alpha"}, {2, "bravo"}, {3, "charlie"}}
+    if err := table.AppendAll(want); err != nil {
+        t.Fatal(err)
+    }
+
+    var got []strRecord
+    if err := table.GetAll(&got); err != nil {
+        t.Fatal(err)
+    }
+    if len(got) != len(want) {
+        t.Fatalf("got %d records, want %d", len(got), len(want))
+    }
+    for i := range want {
+        if got[i] != want[i] {
+            t.Fatalf("record %d: got %+v, want %+v", i, got[i], want[i])
+        }
+    }
+}
+
+// TestCreateDatasetWithPropsChunkedDeflate exercises the chunk0-4
+// DatasetCreateProps path end to end: a chunked, shuffled, deflated
+// dataset is created, written, closed, reopened, and read back.
+func TestCreateDatasetWithPropsChunkedDeflate(t *testing.T) {
+    DisplayErrors(true)
+    defer DisplayErrors(false)
+    defer os.Remove(FNAME)
+
+    f, err := CreateFile(FNAME, F_ACC_TRUNC)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+
+    dims := []uint{100}
+    dspace, err := CreateSimpleDataspace(dims, dims)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    dcpl, err := NewDatasetCreateProps()
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer dcpl.Close()
+    if err := dcpl.SetChunk([]uint{10}); err != nil {
+        t.Fatal(err)
+    }
+    if err := dcpl.SetShuffle(); err != nil {
+        t.Fatal(err)
+    }
+    if err := dcpl.SetDeflate(6); err != nil {
+        t.Fatal(err)
+    }
+    if err := dcpl.SetFillValue(int32(-1), T_NATIVE_INT); err != nil {
+        t.Fatal(err)
+    }
+
+    dset, err := f.CreateDatasetWithProps("chunked", T_NATIVE_INT, dspace, dcpl)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    var data [100]int32
+    for i := range data {
+        data[i] = int32(i * i)
+    }
+    if err := dset.Write(&data[0], T_NATIVE_INT); err != nil {
+        t.Fatal(err)
+    }
+    dset.Close()
+    f.Close()
+
+    f2, err := OpenFile(FNAME, F_ACC_RDONLY)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f2.Close()
+
+    dset2, err := f2.OpenDataset("chunked")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    filespace := dset2.Space()
+    offset, stride, count, block := []uint{0}, []uint{1}, []uint{100}, []uint{1}
+    if err := filespace.SelectHyperslab(offset, stride, count, block); err != nil {
+        t.Fatal(err)
+    }
+    memspace, err := CreateSimpleDataspace(dims, dims)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    var readback [100]int32
+    if err := dset2.ReadSubset(&readback[0], T_NATIVE_INT, memspace, filespace); err != nil {
+        t.Fatal(err)
+    }
+    if readback != data {
+        t.Fatal("round-tripped chunked/deflated data does not match what was written", readback, data)
+    }
+}
+
+// TestDynDatatypeSchema builds, by hand, a compound type with a nested
+// array member and an enum member (structDatatype has no way to derive
+// an enum member from a Go type) and checks that Schema
+// recurses through both correctly, along with OrderedMembers, ArrayDims,
+// Super, and EnumMembers, and that the result actually marshals to JSON.
+func TestDynDatatypeSchema(t *testing.T) {
+    colorHid := C.H5Tenum_create(T_NATIVE_INT.id)
+    if colorHid < 0 {
+        t.Fatal("H5Tenum_create failed")
+    }
+    enumNames := []string{"RED", "GREEN", "BLUE"}
+    for i, name := range enumNames {
+        c_name := C.CString(name)
+        v := C.int(i)
+        rc := C.H5Tenum_insert(colorHid, c_name, unsafe.Pointer(&v))
+        C.free(unsafe.Pointer(c_name))
+        if rc < 0 {
+            t.Fatal("H5Tenum_insert failed")
+        }
+    }
+
+    arrDims := [1]C.hsize_t{3}
+    arrHid := C.H5Tarray_create2(T_NATIVE_INT.id, 1, &arrDims[0])
+    if arrHid < 0 {
+        t.Fatal("H5Tarray_create2 failed")
+    }
+
+    idSize := int(C.H5Tget_size(T_NATIVE_INT.id))
+    arrSize := int(C.H5Tget_size(arrHid))
+    colorSize := int(C.H5Tget_size(colorHid))
+
+    compHid := C.H5Tcreate(C.H5T_COMPOUND, C.size_t(idSize+arrSize+colorSize))
+    if compHid < 0 {
+        t.Fatal("H5Tcreate failed")
+    }
+
+    type member struct {
+        name   string
+        offset int
+        mtype  C.hid_t
+    }
+    members := []member{
+        {"ID", 0, T_NATIVE_INT.id},
+        {"Values", idSize, arrHid},
+        {"Color", idSize + arrSize, colorHid},
+    }
+    for _, m := range members {
+        c_name := C.CString(m.name)
+        rc := C.H5Tinsert(compHid, c_name, C.size_t(m.offset), m.mtype)
+        C.free(unsafe.Pointer(c_name))
+        if rc < 0 {
+            t.Fatalf("H5Tinsert failed for %s", m.name)
+        }
+    }
+    C.H5Tclose(arrHid)
+    C.H5Tclose(colorHid)
+
+    dt := NewDynDatatype(compHid)
+    defer dt.Close()
+
+    schema, err := dt.Schema()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if schema.Class != "compound" || len(schema.Members) != 3 {
+        t.Fatalf("unexpected top-level schema: %+v", schema)
+    }
+    if schema.Members[0].Name != "ID" || schema.Members[0].Schema.Class != "integer" {
+        t.Fatalf("unexpected ID member: %+v", schema.Members[0])
+    }
+
+    values := schema.Members[1].Schema
+    if schema.Members[1].Name != "Values" || values.Class != "array" {
+        t.Fatalf("unexpected Values member: %+v", schema.Members[1])
+    }
+    if len(values.ArrayDims) != 1 || values.ArrayDims[0] != 3 {
+        t.Fatalf("unexpected array dims: %+v", values.ArrayDims)
+    }
+    if values.Element == nil || values.Element.Class != "integer" {
+        t.Fatalf("unexpected array element schema: %+v", values.Element)
+    }
+
+    color := schema.Members[2].Schema
+    if schema.Members[2].Name != "Color" || color.Class != "enum" {
+        t.Fatalf("unexpected Color member: %+v", schema.Members[2])
+    }
+    if len(color.EnumMembers) != 3 || color.EnumMembers["GREEN"] != 1 {
+        t.Fatalf("unexpected enum members: %+v", color.EnumMembers)
+    }
+
+    if _, err := json.Marshal(schema); err != nil {
+        t.Fatalf("Schema did not marshal to JSON: %s", err)
+    }
+}
@@ -0,0 +1,595 @@
+package hdf5
+
+// This file adds a reflection-based binding layer on top of the
+// dynamic-typing primitives in dynamic.go: instead of walking an
+// unknown HDF5 compound type, Dataset.Get/Put and Table.GetAll/AppendAll
+// start from a known Go struct and derive the matching HDF5 compound
+// type (and in-memory layout) from its exported fields, similar to how
+// hdf5.File.Get builds a type from H5Dget_type and then reads straight
+// into caller-supplied memory.
+
+// #include "hdf5.h"
+// #include "H5PTpublic.h"
+// #include <stdlib.h>
+import "C"
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// cFieldLayout describes where a single Go struct field lives within
+// the flat byte buffer used to talk to HDF5 (H5Dread/H5Dwrite/H5PTappend
+// all operate on such buffers). It mirrors one `hdf5:"name,offset,type"`
+// struct tag.
+type cFieldLayout struct {
+	index        int
+	name         string
+	offset       int
+	typ          reflect.Type
+	typeOverride string
+}
+
+// parseHdf5Tag parses the `hdf5:"name,offset,type"` struct tag used to
+// drive the reflection-based Get/Put/GetAll/AppendAll family. Any of the
+// three comma-separated parts may be omitted: a missing name defaults to
+// the Go field name, a missing offset is computed from the preceding
+// fields' sizes, and a missing type is inferred from the field's
+// reflect.Kind.
+func parseHdf5Tag(f reflect.StructField) (name string, offset int, typeOverride string, hasOffset bool) {
+	name = f.Name
+	tag := f.Tag.Get("hdf5")
+	if tag == "" {
+		return name, 0, "", false
+	}
+	parts := strings.Split(tag, ",")
+	if len(parts) > 0 && parts[0] != "" {
+		name = parts[0]
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		if n, err := strconv.Atoi(parts[1]); err == nil {
+			offset = n
+			hasOffset = true
+		}
+	}
+	if len(parts) > 2 {
+		typeOverride = parts[2]
+	}
+	return name, offset, typeOverride, hasOffset
+}
+
+// cSizeOf returns the size, in bytes, that rt occupies in the flat
+// buffer layout used by the reflection binding. It matches rt.Size()
+// for numeric and bool types. A string is represented on the HDF5 side
+// as a single variable-length string pointer (T_GO_STRING) rather than
+// Go's two-word string header, and a struct takes the packed size that
+// cStructLayout computes, without Go's alignment padding; arrays are
+// sized from their elements accordingly.
+func cSizeOf(rt reflect.Type) (int, error) {
+	switch rt.Kind() {
+	case reflect.String:
+		return int(unsafe.Sizeof(uintptr(0))), nil
+	case reflect.Array:
+		elemSize, err := cSizeOf(rt.Elem())
+		if err != nil {
+			return 0, err
+		}
+		return elemSize * rt.Len(), nil
+	case reflect.Struct:
+		_, size, err := cStructLayout(rt)
+		return size, err
+	case reflect.Int8, reflect.Uint8, reflect.Int16, reflect.Uint16,
+		reflect.Int32, reflect.Uint32, reflect.Int64, reflect.Uint64,
+		reflect.Int, reflect.Uint, reflect.Float32, reflect.Float64, reflect.Bool:
+		return int(rt.Size()), nil
+	default:
+		return 0, dynamic_error(7320)
+	}
+}
+
+// cStructLayout computes the flat-buffer offset of every exported field
+// of rt, honoring `hdf5` tag overrides, and returns the total record
+// size. It is the single source of truth shared by structDatatype (which
+// turns it into a real HDF5 compound type) and decodeRecord/encodeRecord
+// (which walk it in Go once per record, calling into C only to
+// allocate a C string for each string field on encode).
+func cStructLayout(rt reflect.Type) ([]cFieldLayout, int, error) {
+	offset := 0
+	var layout []cFieldLayout
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" {
+			// unexported field: not part of the HDF5 record
+			continue
+		}
+		name, tagOffset, typeOverride, hasOffset := parseHdf5Tag(f)
+		size, err := cSizeOf(f.Type)
+		if err != nil {
+			return nil, 0, err
+		}
+		if typeOverride != "" {
+			osize, err := h5SizeByName(typeOverride)
+			if err != nil {
+				return nil, 0, err
+			}
+			if osize != size {
+				// the override must describe a type the same width as
+				// the Go field, since that width is already baked into
+				// this field's offset and every later field's offset
+				return nil, 0, dynamic_error(7326)
+			}
+		}
+		off := offset
+		if hasOffset {
+			off = tagOffset
+		}
+		layout = append(layout, cFieldLayout{index: i, name: name, offset: off, typ: f.Type, typeOverride: typeOverride})
+		offset = off + size
+	}
+	return layout, offset, nil
+}
+
+// goTypeToH5Type creates a new HDF5 datatype describing rt. The caller
+// owns the returned DynDatatype and must Close it once it has been
+// inserted into (copied by) a compound type.
+func goTypeToH5Type(rt reflect.Type) (*DynDatatype, error) {
+	switch rt.Kind() {
+	case reflect.Int8:
+		return dynCopy(T_NATIVE_SCHAR), nil
+	case reflect.Uint8:
+		return dynCopy(T_NATIVE_UCHAR), nil
+	case reflect.Int16:
+		return dynCopy(T_NATIVE_SHORT), nil
+	case reflect.Uint16:
+		return dynCopy(T_NATIVE_USHORT), nil
+	case reflect.Int32:
+		return dynCopy(T_NATIVE_INT), nil
+	case reflect.Uint32:
+		return dynCopy(T_NATIVE_UINT), nil
+	case reflect.Int64, reflect.Int:
+		return dynCopy(T_NATIVE_LLONG), nil
+	case reflect.Uint64, reflect.Uint:
+		return dynCopy(T_NATIVE_ULLONG), nil
+	case reflect.Float32:
+		return dynCopy(T_NATIVE_FLOAT), nil
+	case reflect.Float64:
+		return dynCopy(T_NATIVE_DOUBLE), nil
+	case reflect.Bool:
+		return dynCopy(T_NATIVE_HBOOL), nil
+	case reflect.String:
+		return dynCopy(T_GO_STRING), nil
+	case reflect.Array:
+		elem, err := goTypeToH5Type(rt.Elem())
+		if err != nil {
+			return nil, err
+		}
+		defer elem.Close()
+		dims := [1]C.hsize_t{C.hsize_t(rt.Len())}
+		hid := C.H5Tarray_create2(elem.id, 1, &dims[0])
+		if hid < 0 {
+			return nil, dynamic_error(7321)
+		}
+		return NewDynDatatype(hid), nil
+	case reflect.Struct:
+		dt, _, _, err := structDatatype(rt)
+		return dt, err
+	default:
+		return nil, dynamic_error(7320)
+	}
+}
+
+// dynCopy wraps a shared, package-level static Datatype (e.g.
+// T_NATIVE_INT) in a fresh DynDatatype the caller can Close
+// independently of the shared handle.
+func dynCopy(t *Datatype) *DynDatatype {
+	return NewDynDatatype(C.H5Tcopy(t.id))
+}
+
+// h5SizeByName returns the on-the-wire size of the type named by an
+// `hdf5:"name,offset,type"` tag's type component. Used to validate that
+// a type override doesn't change a field's width out from under the
+// layout cStructLayout already computed for it. It defers to
+// h5TypeByName rather than keeping its own name->size table, so the two
+// can't drift apart.
+func h5SizeByName(name string) (int, error) {
+	t, err := h5TypeByName(name)
+	if err != nil {
+		return 0, err
+	}
+	defer t.Close()
+	return int(t.Size()), nil
+}
+
+// h5TypeByName creates the HDF5 datatype named by an
+// `hdf5:"name,offset,type"` tag's type component, e.g. to force a Go
+// int32 field to be stored as an unsigned HDF5 member.
+func h5TypeByName(name string) (*DynDatatype, error) {
+	switch name {
+	case "int8":
+		return dynCopy(T_NATIVE_SCHAR), nil
+	case "uint8":
+		return dynCopy(T_NATIVE_UCHAR), nil
+	case "int16":
+		return dynCopy(T_NATIVE_SHORT), nil
+	case "uint16":
+		return dynCopy(T_NATIVE_USHORT), nil
+	case "int32":
+		return dynCopy(T_NATIVE_INT), nil
+	case "uint32":
+		return dynCopy(T_NATIVE_UINT), nil
+	case "int64":
+		return dynCopy(T_NATIVE_LLONG), nil
+	case "uint64":
+		return dynCopy(T_NATIVE_ULLONG), nil
+	case "float32":
+		return dynCopy(T_NATIVE_FLOAT), nil
+	case "float64":
+		return dynCopy(T_NATIVE_DOUBLE), nil
+	case "bool":
+		return dynCopy(T_NATIVE_HBOOL), nil
+	case "string":
+		return dynCopy(T_GO_STRING), nil
+	default:
+		return nil, dynamic_error(7327)
+	}
+}
+
+// fieldH5Type resolves the HDF5 datatype to use for a struct member,
+// honoring an explicit `hdf5:"...,...,type"` override if present and
+// otherwise inferring it from the Go field type.
+func fieldH5Type(m cFieldLayout) (*DynDatatype, error) {
+	if m.typeOverride != "" {
+		return h5TypeByName(m.typeOverride)
+	}
+	return goTypeToH5Type(m.typ)
+}
+
+// structDatatype builds the HDF5 compound type describing rt's exported
+// fields, laid out exactly as cStructLayout computes, so that the
+// returned datatype can be used as the in-memory type of an H5Dread,
+// H5Dwrite, or H5PTappend call whose buffer was encoded/decoded with the
+// same layout.
+func structDatatype(rt reflect.Type) (*DynDatatype, []cFieldLayout, int, error) {
+	if rt.Kind() != reflect.Struct {
+		return nil, nil, 0, dynamic_error(7320)
+	}
+	layout, size, err := cStructLayout(rt)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	hid := C.H5Tcreate(C.H5T_COMPOUND, C.size_t(size))
+	if hid < 0 {
+		return nil, nil, 0, dynamic_error(7322)
+	}
+	for _, m := range layout {
+		mtype, err := fieldH5Type(m)
+		if err != nil {
+			C.H5Tclose(hid)
+			return nil, nil, 0, err
+		}
+		c_name := C.CString(m.name)
+		rc := C.H5Tinsert(hid, c_name, C.size_t(m.offset), mtype.id)
+		C.free(unsafe.Pointer(c_name))
+		mtype.Close()
+		if rc < 0 {
+			C.H5Tclose(hid)
+			return nil, nil, 0, h5err(rc)
+		}
+	}
+	return NewDynDatatype(hid), layout, size, nil
+}
+
+// decodeRecord copies one flat-buffer record at base into the exported
+// fields of the struct v, recursing into nested structs and arrays.
+func decodeRecord(v reflect.Value, base unsafe.Pointer, layout []cFieldLayout) {
+	for _, m := range layout {
+		decodeValue(v.Field(m.index), unsafe.Pointer(uintptr(base)+uintptr(m.offset)))
+	}
+}
+
+func decodeValue(fv reflect.Value, p unsafe.Pointer) {
+	switch fv.Kind() {
+	case reflect.String:
+		c_str := *(*uintptr)(p)
+		if c_str != 0 {
+			fv.SetString(C.GoString((*C.char)(unsafe.Pointer(c_str))))
+		}
+	case reflect.Struct:
+		nested, _, _ := cStructLayout(fv.Type())
+		decodeRecord(fv, p, nested)
+	case reflect.Array:
+		elemSize, _ := cSizeOf(fv.Type().Elem())
+		for j := 0; j < fv.Len(); j++ {
+			decodeValue(fv.Index(j), unsafe.Pointer(uintptr(p)+uintptr(j*elemSize)))
+		}
+	default:
+		fv.Set(reflect.NewAt(fv.Type(), p).Elem())
+	}
+}
+
+// encodeRecord copies the exported fields of the struct v into the flat
+// buffer at base. Any string field allocates a C string via C.CString,
+// appending it to *strs so the caller can free it once the HDF5 write
+// that consumes the buffer has returned.
+func encodeRecord(v reflect.Value, base unsafe.Pointer, layout []cFieldLayout, strs *[]*C.char) {
+	for _, m := range layout {
+		encodeValue(v.Field(m.index), unsafe.Pointer(uintptr(base)+uintptr(m.offset)), strs)
+	}
+}
+
+func encodeValue(fv reflect.Value, p unsafe.Pointer, strs *[]*C.char) {
+	switch fv.Kind() {
+	case reflect.String:
+		c_str := C.CString(fv.String())
+		*strs = append(*strs, c_str)
+		*(*uintptr)(p) = uintptr(unsafe.Pointer(c_str))
+	case reflect.Struct:
+		nested, _, _ := cStructLayout(fv.Type())
+		encodeRecord(fv, p, nested, strs)
+	case reflect.Array:
+		elemSize, _ := cSizeOf(fv.Type().Elem())
+		for j := 0; j < fv.Len(); j++ {
+			encodeValue(fv.Index(j), unsafe.Pointer(uintptr(p)+uintptr(j*elemSize)), strs)
+		}
+	default:
+		reflect.NewAt(fv.Type(), p).Elem().Set(fv)
+	}
+}
+
+func freeCStrings(strs []*C.char) {
+	for _, s := range strs {
+		C.free(unsafe.Pointer(s))
+	}
+}
+
+// reclaimVlen releases any VLEN/string buffers the HDF5 C library
+// allocated while filling in ptr (n records of dt), using a throwaway
+// simple dataspace describing that same shape. This must run only after
+// the Go-owned data has already been extracted from ptr via
+// decodeRecord, since it frees the underlying C memory. This is the fix
+// for the "god knows what we're leaking" problem in stringUnpacker.
+func reclaimVlen(dt *DynDatatype, n int64, ptr unsafe.Pointer) error {
+	if ptr == nil {
+		return nil
+	}
+	dims := [1]C.hsize_t{C.hsize_t(n)}
+	memspace := C.H5Screate_simple(1, &dims[0], nil)
+	if memspace < 0 {
+		return dynamic_error(7323)
+	}
+	defer C.H5Sclose(memspace)
+	rc := C.H5Dvlen_reclaim(dt.id, memspace, C.H5P_DEFAULT, ptr)
+	if rc < 0 {
+		return h5err(rc)
+	}
+	return nil
+}
+
+// Get reads the full contents of the dataset into dest, which must be a
+// pointer to a struct (for a scalar dataset) or a pointer to a slice of
+// structs (for a 1-dimensional dataset of compound records). Field
+// mapping is by exported Go field name, overridable with an
+// `hdf5:"name,offset,type"` struct tag.
+func (d *Dataset) Get(dest interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr {
+		return dynamic_error(7330)
+	}
+	elem := rv.Elem()
+	switch elem.Kind() {
+	case reflect.Slice:
+		structType := elem.Type().Elem()
+		dt, layout, size, err := structDatatype(structType)
+		if err != nil {
+			return err
+		}
+		defer dt.Close()
+
+		space := d.Space()
+		n := int64(C.H5Sget_simple_extent_npoints(C.hid_t(space.id)))
+
+		buf := make([]byte, int(n)*size)
+		var bufptr unsafe.Pointer
+		if len(buf) > 0 {
+			bufptr = unsafe.Pointer(&buf[0])
+		}
+		rc := C.H5Dread(d.id, dt.id, C.H5S_ALL, C.H5S_ALL, C.H5P_DEFAULT, bufptr)
+		if rc < 0 {
+			return h5err(rc)
+		}
+
+		elem.Set(reflect.MakeSlice(elem.Type(), int(n), int(n)))
+		for i := 0; i < int(n); i++ {
+			decodeRecord(elem.Index(i), unsafe.Pointer(uintptr(bufptr)+uintptr(i*size)), layout)
+		}
+		return reclaimVlen(dt, n, bufptr)
+
+	case reflect.Struct:
+		dt, layout, size, err := structDatatype(elem.Type())
+		if err != nil {
+			return err
+		}
+		defer dt.Close()
+
+		buf := make([]byte, size)
+		var bufptr unsafe.Pointer
+		if size > 0 {
+			bufptr = unsafe.Pointer(&buf[0])
+		}
+		rc := C.H5Dread(d.id, dt.id, C.H5S_ALL, C.H5S_ALL, C.H5P_DEFAULT, bufptr)
+		if rc < 0 {
+			return h5err(rc)
+		}
+		decodeRecord(elem, bufptr, layout)
+		return reclaimVlen(dt, 1, bufptr)
+
+	default:
+		return dynamic_error(7331)
+	}
+}
+
+// Put writes src, a struct or a slice of structs, to the dataset using
+// the same field mapping rules as Get.
+func (d *Dataset) Put(src interface{}) error {
+	rv := reflect.ValueOf(src)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Slice:
+		structType := rv.Type().Elem()
+		dt, layout, size, err := structDatatype(structType)
+		if err != nil {
+			return err
+		}
+		defer dt.Close()
+
+		n := rv.Len()
+		buf := make([]byte, n*size)
+		var strs []*C.char
+		for i := 0; i < n; i++ {
+			encodeRecord(rv.Index(i), unsafe.Pointer(uintptr(unsafe.Pointer(&buf[0]))+uintptr(i*size)), layout, &strs)
+		}
+		defer freeCStrings(strs)
+
+		var bufptr unsafe.Pointer
+		if len(buf) > 0 {
+			bufptr = unsafe.Pointer(&buf[0])
+		}
+		rc := C.H5Dwrite(d.id, dt.id, C.H5S_ALL, C.H5S_ALL, C.H5P_DEFAULT, bufptr)
+		if rc < 0 {
+			return h5err(rc)
+		}
+		return nil
+
+	case reflect.Struct:
+		dt, layout, size, err := structDatatype(rv.Type())
+		if err != nil {
+			return err
+		}
+		defer dt.Close()
+
+		buf := make([]byte, size)
+		var strs []*C.char
+		var bufptr unsafe.Pointer
+		if size > 0 {
+			bufptr = unsafe.Pointer(&buf[0])
+		}
+		encodeRecord(rv, bufptr, layout, &strs)
+		defer freeCStrings(strs)
+
+		rc := C.H5Dwrite(d.id, dt.id, C.H5S_ALL, C.H5S_ALL, C.H5P_DEFAULT, bufptr)
+		if rc < 0 {
+			return h5err(rc)
+		}
+		return nil
+
+	default:
+		return dynamic_error(7332)
+	}
+}
+
+// verifyTableLayout checks that structDT, the HDF5 compound type built
+// from a Go struct by structDatatype, is identical (via H5Tequal) to
+// table's actual on-disk datatype. GetAll and AppendAll reinterpret the
+// table's raw bytes directly rather than asking H5PTget_next/H5PTappend
+// to convert between a file type and a memory type the way Dataset.Get
+// and Put do, so an undetected mismatch here would silently decode or
+// write garbage.
+func verifyTableLayout(structDT *DynDatatype, table *Table) error {
+	dt, err := openTableDatatype(table)
+	if err != nil {
+		return err
+	}
+	defer dt.Close()
+	if C.H5Tequal(structDT.id, dt.id) <= 0 {
+		return dynamic_error(7343)
+	}
+	return nil
+}
+
+// GetAll reads every packet in the table, starting from the first, into
+// dest, a pointer to a slice of structs, using the same field mapping
+// rules as Dataset.Get.
+func (t *Table) GetAll(dest interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return dynamic_error(7340)
+	}
+	elem := rv.Elem()
+	structType := elem.Type().Elem()
+	structDT, layout, size, err := structDatatype(structType)
+	if err != nil {
+		return err
+	}
+	defer structDT.Close()
+	if err := verifyTableLayout(structDT, t); err != nil {
+		return err
+	}
+
+	rdr, err := t.MakeTableReader()
+	if err != nil {
+		return err
+	}
+
+	var out []reflect.Value
+	for rdr.Next() {
+		rec := rdr.Record()
+		if len(rec) < size {
+			return dynamic_error(7341)
+		}
+		v := reflect.New(structType).Elem()
+		decodeRecord(v, unsafe.Pointer(&rec[0]), layout)
+		out = append(out, v)
+	}
+	if err := rdr.Err(); err != nil {
+		return err
+	}
+
+	result := reflect.MakeSlice(elem.Type(), len(out), len(out))
+	for i, v := range out {
+		result.Index(i).Set(v)
+	}
+	elem.Set(result)
+	return nil
+}
+
+// AppendAll appends every element of src, a slice of structs, to the
+// table as a single H5PTappend call.
+func (t *Table) AppendAll(src interface{}) error {
+	rv := reflect.ValueOf(src)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice {
+		return dynamic_error(7342)
+	}
+	structType := rv.Type().Elem()
+	structDT, layout, size, err := structDatatype(structType)
+	if err != nil {
+		return err
+	}
+	defer structDT.Close()
+	if err := verifyTableLayout(structDT, t); err != nil {
+		return err
+	}
+
+	n := rv.Len()
+	if n == 0 {
+		return nil
+	}
+	buf := make([]byte, n*size)
+	var strs []*C.char
+	for i := 0; i < n; i++ {
+		encodeRecord(rv.Index(i), unsafe.Pointer(uintptr(unsafe.Pointer(&buf[0]))+uintptr(i*size)), layout, &strs)
+	}
+	defer freeCStrings(strs)
+
+	rc := C.H5PTappend(t.id, C.size_t(n), unsafe.Pointer(&buf[0]))
+	if rc < 0 {
+		return h5err(rc)
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+package hdf5
+
+// This file extends DynDatatype with the recursive introspection needed
+// to fully describe an unknown HDF5 type: arrays, VLEN, enums, and a
+// JSON-serializable Schema dump. It's what lets a caller walk a
+// compound type it didn't define at compile time, the same kind of
+// walk the reflection-based Get/Put in reflect.go does in the opposite
+// direction (Go struct -> HDF5 type).
+
+// #include "hdf5.h"
+// #include <stdlib.h>
+import "C"
+
+import (
+	"unsafe"
+)
+
+// ArrayDims returns the dimensions of an H5T_ARRAY datatype.
+func (t *DynDatatype) ArrayDims() ([]uint, error) {
+	if t.class != T_ARRAY {
+		return nil, dynamic_error(7420)
+	}
+	ndims := int(C.H5Tget_array_ndims(t.id))
+	if ndims <= 0 {
+		return nil, dynamic_error(7355)
+	}
+	c_dims := make([]C.hsize_t, ndims)
+	if C.H5Tget_array_dims2(t.id, &c_dims[0]) < 0 {
+		return nil, dynamic_error(7356)
+	}
+	dims := make([]uint, ndims)
+	for i, d := range c_dims {
+		dims[i] = uint(d)
+	}
+	return dims, nil
+}
+
+// Super returns the base datatype of an array, VLEN, or enum datatype
+// (via H5Tget_super). The caller owns the returned DynDatatype.
+func (t *DynDatatype) Super() (*DynDatatype, error) {
+	switch t.class {
+	case T_ARRAY, T_VLEN, T_ENUM:
+		hid := C.H5Tget_super(t.id)
+		if hid < 0 {
+			return nil, dynamic_error(7421)
+		}
+		return NewDynDatatype(hid), nil
+	default:
+		return nil, dynamic_error(7422)
+	}
+}
+
+// EnumMembers returns the name -> value map of an enum datatype.
+func (t *DynDatatype) EnumMembers() (map[string]int64, error) {
+	if t.class != T_ENUM {
+		return nil, dynamic_error(7423)
+	}
+	super, err := t.Super()
+	if err != nil {
+		return nil, err
+	}
+	defer super.Close()
+	under, err := super.primitiveUnpacker()
+	if err != nil {
+		return nil, err
+	}
+
+	n := int(C.H5Tget_nmembers(t.id))
+	buf := make([]byte, int(super.Size()))
+	out := make(map[string]int64, n)
+	for i := 0; i < n; i++ {
+		c_name := C.H5Tget_member_name(t.id, C.uint(i))
+		name := C.GoString(c_name)
+		C.free(unsafe.Pointer(c_name))
+		rc := C.H5Tget_member_value(t.id, C.uint(i), unsafe.Pointer(&buf[0]))
+		if rc < 0 {
+			return nil, h5err(rc)
+		}
+		v, _ := under(uintptr(unsafe.Pointer(&buf[0])))
+		out[name] = toInt64(v)
+	}
+	return out, nil
+}
+
+// IsVariableString reports whether a string datatype is variable-length
+// (H5Tis_variable_str) as opposed to fixed-length.
+func (t *DynDatatype) IsVariableString() (bool, error) {
+	if t.class != T_STRING {
+		return false, dynamic_error(7424)
+	}
+	rc := C.H5Tis_variable_str(t.id)
+	if rc < 0 {
+		return false, h5err(C.herr_t(rc))
+	}
+	return rc > 0, nil
+}
+
+// StringPad identifies the padding HDF5 applies to a fixed-length
+// string's unused trailing bytes.
+type StringPad int
+
+const (
+	STR_NULLTERM StringPad = C.H5T_STR_NULLTERM
+	STR_NULLPAD  StringPad = C.H5T_STR_NULLPAD
+	STR_SPACEPAD StringPad = C.H5T_STR_SPACEPAD
+)
+
+// StringPadding returns a string datatype's padding scheme.
+func (t *DynDatatype) StringPadding() (StringPad, error) {
+	if t.class != T_STRING {
+		return 0, dynamic_error(7425)
+	}
+	return StringPad(C.H5Tget_strpad(t.id)), nil
+}
+
+// CharSet identifies the character encoding of a string datatype.
+type CharSet int
+
+const (
+	CSET_ASCII CharSet = C.H5T_CSET_ASCII
+	CSET_UTF8  CharSet = C.H5T_CSET_UTF8
+)
+
+// CharSet returns a string datatype's character set.
+func (t *DynDatatype) CharSet() (CharSet, error) {
+	if t.class != T_STRING {
+		return 0, dynamic_error(7426)
+	}
+	return CharSet(C.H5Tget_cset(t.id)), nil
+}
+
+// Sign identifies whether an integer datatype is signed.
+type Sign int
+
+const (
+	SGN_NONE Sign = C.H5T_SGN_NONE
+	SGN_2    Sign = C.H5T_SGN_2
+)
+
+// Sign returns an integer datatype's signedness.
+func (t *DynDatatype) Sign() (Sign, error) {
+	if t.class != T_INTEGER {
+		return 0, dynamic_error(7427)
+	}
+	s := C.H5Tget_sign(t.id)
+	if int(s) < 0 {
+		return 0, dynamic_error(7428)
+	}
+	return Sign(s), nil
+}
+
+// Member describes one member of a compound datatype.
+type Member struct {
+	Name   string
+	Offset int
+	Type   *DynDatatype
+}
+
+// OrderedMembers is like Members, but returns a []Member that preserves
+// declaration order (and thus offset order), which the unordered map
+// returned by Members loses.
+func (t *DynDatatype) OrderedMembers() ([]Member, error) {
+	if t.class != T_COMPOUND {
+		return nil, dynamic_error(7303)
+	}
+	n, _ := t.NMembers()
+	out := make([]Member, n)
+	for i := 0; i < n; i++ {
+		mtype, err := t.MemberType(i)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = Member{Name: t.MemberName(i), Offset: t.MemberOffset(i), Type: mtype}
+	}
+	return out, nil
+}
+
+// Schema is the JSON-serializable description of a DynDatatype's
+// layout, recursing into nested compounds, arrays, VLEN, and enums.
+// It lets a caller dump/diff/tool around the layout of an unknown
+// compound type, e.g. to drive the reflection-based Get/Put in
+// reflect.go.
+type Schema struct {
+	Class       string           `json:"class"`
+	Size        uint             `json:"size"`
+	Members     []MemberSchema   `json:"members,omitempty"`
+	ArrayDims   []uint           `json:"array_dims,omitempty"`
+	Element     *Schema          `json:"element,omitempty"`
+	EnumMembers map[string]int64 `json:"enum_members,omitempty"`
+	VariableStr bool             `json:"variable_str,omitempty"`
+	Unsigned    bool             `json:"unsigned,omitempty"`
+}
+
+// MemberSchema describes one member within a compound Schema.
+type MemberSchema struct {
+	Name   string `json:"name"`
+	Offset int    `json:"offset"`
+	Schema Schema `json:"schema"`
+}
+
+func (t *DynDatatype) className() string {
+	switch t.class {
+	case T_COMPOUND:
+		return "compound"
+	case T_ARRAY:
+		return "array"
+	case T_VLEN:
+		return "vlen"
+	case T_ENUM:
+		return "enum"
+	case T_STRING:
+		return "string"
+	case T_INTEGER:
+		return "integer"
+	case T_FLOAT:
+		return "float"
+	default:
+		return "unknown"
+	}
+}
+
+// Schema recursively describes t, including nested compound members,
+// array element types, VLEN base types, and enum value/name pairs.
+func (t *DynDatatype) Schema() (Schema, error) {
+	s := Schema{Class: t.className(), Size: t.Size()}
+
+	switch t.class {
+	case T_COMPOUND:
+		members, err := t.OrderedMembers()
+		if err != nil {
+			return s, err
+		}
+		s.Members = make([]MemberSchema, len(members))
+		for i, m := range members {
+			sub, err := m.Type.Schema()
+			if err != nil {
+				return s, err
+			}
+			s.Members[i] = MemberSchema{Name: m.Name, Offset: m.Offset, Schema: sub}
+		}
+
+	case T_ARRAY:
+		dims, err := t.ArrayDims()
+		if err != nil {
+			return s, err
+		}
+		s.ArrayDims = dims
+		super, err := t.Super()
+		if err != nil {
+			return s, err
+		}
+		defer super.Close()
+		sub, err := super.Schema()
+		if err != nil {
+			return s, err
+		}
+		s.Element = &sub
+
+	case T_VLEN:
+		super, err := t.Super()
+		if err != nil {
+			return s, err
+		}
+		defer super.Close()
+		sub, err := super.Schema()
+		if err != nil {
+			return s, err
+		}
+		s.Element = &sub
+
+	case T_ENUM:
+		members, err := t.EnumMembers()
+		if err != nil {
+			return s, err
+		}
+		s.EnumMembers = members
+
+	case T_STRING:
+		isVar, err := t.IsVariableString()
+		if err != nil {
+			return s, err
+		}
+		s.VariableStr = isVar
+
+	case T_INTEGER:
+		sign, err := t.Sign()
+		if err != nil {
+			return s, err
+		}
+		s.Unsigned = sign == SGN_NONE
+	}
+
+	return s, nil
+}